@@ -0,0 +1,294 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// macEpoch is the reference date bplist stores dates relative to: midnight
+// UTC on 2001-01-01.
+var macEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// flatObject is a single entry in a bplist object table: either a leaf
+// (kind/value populated) or a container (refs populated with indices into
+// the object table, built up by bplistGenerator.flatten).
+type flatObject struct {
+	kind  plistKind
+	value interface{}
+	refs  []int // Array: one ref per element. Dictionary: key refs followed by value refs.
+}
+
+// bplistGenerator serializes a plistValue tree into Apple's "bplist00"
+// binary format.
+type bplistGenerator struct {
+	w       io.Writer
+	objs    []flatObject
+	primIdx map[string]int
+	contIdx map[*plistValue]int
+}
+
+func newBplistGenerator(w io.Writer) *bplistGenerator {
+	return &bplistGenerator{
+		w:       w,
+		primIdx: make(map[string]int),
+		contIdx: make(map[*plistValue]int),
+	}
+}
+
+func (g *bplistGenerator) generateDocument(root *plistValue) error {
+	top := g.flatten(root)
+
+	refSize := intSizeForCount(len(g.objs))
+
+	encoded := make([][]byte, len(g.objs))
+	for i, obj := range g.objs {
+		encoded[i] = g.encodeObject(obj, refSize)
+	}
+
+	offsets := make([]uint64, len(g.objs))
+	var buf bytes.Buffer
+	buf.WriteString("bplist00")
+	for i, b := range encoded {
+		offsets[i] = uint64(buf.Len())
+		buf.Write(b)
+	}
+
+	offsetTableOffset := uint64(buf.Len())
+	offsetIntSize := intSizeForCount(int(offsetTableOffset) + 1)
+	for _, off := range offsets {
+		writeUintBE(&buf, off, offsetIntSize)
+	}
+
+	// Trailer: 6 pad bytes, offsetIntSize, objectRefSize, numObjects,
+	// topObject, offsetTableOffset.
+	buf.Write(make([]byte, 6))
+	buf.WriteByte(byte(offsetIntSize))
+	buf.WriteByte(byte(refSize))
+	writeUintBE(&buf, uint64(len(g.objs)), 8)
+	writeUintBE(&buf, uint64(top), 8)
+	writeUintBE(&buf, offsetTableOffset, 8)
+
+	_, err := g.w.Write(buf.Bytes())
+	return err
+}
+
+// flatten assigns every reachable value an index in the object table,
+// deduplicating primitives by value. Containers are shared only when the
+// same *plistValue is reached more than once; two distinct dicts/arrays
+// with equal contents get separate entries.
+func (g *bplistGenerator) flatten(pval *plistValue) int {
+	if pval == nil {
+		pval = &plistValue{Invalid, nil}
+	}
+
+	switch pval.kind {
+	case Array:
+		if idx, ok := g.contIdx[pval]; ok {
+			return idx
+		}
+		idx := len(g.objs)
+		g.objs = append(g.objs, flatObject{kind: Array})
+		g.contIdx[pval] = idx
+
+		subvalues := pval.value.([]*plistValue)
+		refs := make([]int, len(subvalues))
+		for i, sub := range subvalues {
+			refs[i] = g.flatten(sub)
+		}
+		g.objs[idx].refs = refs
+		return idx
+	case Dictionary:
+		if idx, ok := g.contIdx[pval]; ok {
+			return idx
+		}
+		idx := len(g.objs)
+		g.objs = append(g.objs, flatObject{kind: Dictionary})
+		g.contIdx[pval] = idx
+
+		keys, values := dictionaryEntries(pval)
+		refs := make([]int, 0, len(keys)+len(values))
+		for _, k := range keys {
+			refs = append(refs, g.flatten(&plistValue{String, k}))
+		}
+		for _, v := range values {
+			refs = append(refs, g.flatten(v))
+		}
+		g.objs[idx].refs = refs
+		return idx
+	default:
+		key := primitiveKey(pval)
+		if idx, ok := g.primIdx[key]; ok {
+			return idx
+		}
+		idx := len(g.objs)
+		g.objs = append(g.objs, flatObject{kind: pval.kind, value: pval.value})
+		g.primIdx[key] = idx
+		return idx
+	}
+}
+
+// primitiveKey returns a string uniquely identifying a leaf plistValue by
+// kind and content, used to deduplicate the object table.
+func primitiveKey(pval *plistValue) string {
+	if pval.kind == Data {
+		return fmt.Sprintf("%d:%s", pval.kind, pval.value.([]byte))
+	}
+	return fmt.Sprintf("%d:%v", pval.kind, pval.value)
+}
+
+func (g *bplistGenerator) encodeObject(obj flatObject, refSize int) []byte {
+	var buf bytes.Buffer
+	switch obj.kind {
+	case Invalid:
+		buf.WriteByte(0x00)
+	case Boolean:
+		if obj.value.(bool) {
+			buf.WriteByte(0x09)
+		} else {
+			buf.WriteByte(0x08)
+		}
+	case Integer:
+		n, width := intBytesFor(obj.value)
+		buf.WriteByte(byte(0x10 | widthPow(width)))
+		writeUintBE(&buf, n, width)
+	case Real:
+		buf.WriteByte(0x23)
+		writeUintBE(&buf, math.Float64bits(obj.value.(float64)), 8)
+	case Date:
+		buf.WriteByte(0x33)
+		seconds := obj.value.(time.Time).Sub(macEpoch).Seconds()
+		writeUintBE(&buf, math.Float64bits(seconds), 8)
+	case UIDKind:
+		n, width := intBytesFor(uint64(obj.value.(UID)))
+		buf.WriteByte(byte(0x80 | (width - 1)))
+		writeUintBE(&buf, n, width)
+	case Data:
+		b := obj.value.([]byte)
+		writeLengthMarker(&buf, 0x40, len(b))
+		buf.Write(b)
+	case String:
+		s := obj.value.(string)
+		if isASCII(s) {
+			writeLengthMarker(&buf, 0x50, len(s))
+			buf.WriteString(s)
+		} else {
+			units := utf16.Encode([]rune(s))
+			writeLengthMarker(&buf, 0x60, len(units))
+			for _, u := range units {
+				writeUintBE(&buf, uint64(u), 2)
+			}
+		}
+	case Array:
+		writeLengthMarker(&buf, 0xA0, len(obj.refs))
+		for _, ref := range obj.refs {
+			writeUintBE(&buf, uint64(ref), refSize)
+		}
+	case Dictionary:
+		count := len(obj.refs) / 2
+		writeLengthMarker(&buf, 0xD0, count)
+		for _, ref := range obj.refs {
+			writeUintBE(&buf, uint64(ref), refSize)
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeLengthMarker writes a marker byte for base|length if length fits in
+// a nibble (<15), otherwise base|0xF followed by an inline integer object
+// encoding the length.
+func writeLengthMarker(buf *bytes.Buffer, base byte, length int) {
+	if length < 0x0F {
+		buf.WriteByte(base | byte(length))
+		return
+	}
+	buf.WriteByte(base | 0x0F)
+	n, width := intBytesFor(int64(length))
+	buf.WriteByte(0x10 | byte(widthPow(width)))
+	writeUintBE(buf, n, width)
+}
+
+// intBytesFor returns the big-endian two's-complement bit pattern of v
+// (an int64 or uint64) along with the smallest power-of-two byte width
+// (1, 2, 4, or 8) that can hold it.
+func intBytesFor(v interface{}) (uint64, int) {
+	switch n := v.(type) {
+	case int64:
+		switch {
+		case n >= -0x80 && n <= 0x7F:
+			return uint64(uint8(int8(n))), 1
+		case n >= -0x8000 && n <= 0x7FFF:
+			return uint64(uint16(int16(n))), 2
+		case n >= -0x80000000 && n <= 0x7FFFFFFF:
+			return uint64(uint32(int32(n))), 4
+		default:
+			return uint64(n), 8
+		}
+	case uint64:
+		switch {
+		case n <= 0x7F:
+			return n, 1
+		case n <= 0x7FFF:
+			return n, 2
+		case n <= 0x7FFFFFFF:
+			return n, 4
+		default:
+			return n, 8
+		}
+	}
+	return 0, 1
+}
+
+func widthPow(width int) int {
+	switch width {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	case 4:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func intSizeForCount(n int) int {
+	switch {
+	case n <= 0xFF:
+		return 1
+	case n <= 0xFFFF:
+		return 2
+	case n <= 0xFFFFFFFF:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func writeUintBE(buf *bytes.Buffer, v uint64, width int) {
+	b := make([]byte, width)
+	switch width {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.BigEndian.PutUint32(b, uint32(v))
+	case 8:
+		binary.BigEndian.PutUint64(b, v)
+	}
+	buf.Write(b)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
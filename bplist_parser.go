@@ -0,0 +1,337 @@
+package plist
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// bplistParser reads a plistValue tree back out of an Apple "bplist00"
+// binary property list.
+//
+// Binary plists routinely arrive from outside the process (.mobileprovision
+// files, iTunes backups, NSKeyedArchiver blobs) and so may be truncated or
+// corrupted. Every offset and length pulled out of the document is
+// validated against the remaining data before it is used to slice p.data,
+// so malformed input produces a decode error instead of a slice-bounds or
+// makeslice panic.
+type bplistParser struct {
+	data          []byte
+	offsetIntSize int
+	objectRefSize int
+	numObjects    int
+	topObject     int
+	offsetTable   []uint64
+	parsing       map[int]bool // object indices currently being parsed, to detect reference cycles
+}
+
+// maxBplistDepth bounds container nesting (arrays/dicts referencing other
+// containers). A legitimate document never nests this deep; a much larger
+// value would let adversarial input drive parseObject's recursion deep
+// enough to overflow the goroutine stack.
+const maxBplistDepth = 10000
+
+func newBplistParser(data []byte) *bplistParser {
+	return &bplistParser{data: data}
+}
+
+const bplistTrailerSize = 32
+
+// checkBounds reports an error unless the byte range [start, start+length)
+// lies entirely within p.data.
+func (p *bplistParser) checkBounds(start, length int) error {
+	if start < 0 || length < 0 || start > len(p.data) || length > len(p.data)-start {
+		return fmt.Errorf("plist: bplist: corrupt document (offset %d, length %d, size %d)", start, length, len(p.data))
+	}
+	return nil
+}
+
+// checkCount reports an error unless count*elemSize bytes are available
+// starting at contentStart. It rejects the multiplication up front (via
+// integer division) so a huge count can't overflow into a small, falsely
+// "in range" byte length.
+func (p *bplistParser) checkCount(contentStart, count, elemSize int) error {
+	if elemSize <= 0 {
+		return fmt.Errorf("plist: bplist: invalid element size %d", elemSize)
+	}
+	if contentStart < 0 || contentStart > len(p.data) {
+		return fmt.Errorf("plist: bplist: corrupt object offset %d", contentStart)
+	}
+	if count < 0 || count > (len(p.data)-contentStart)/elemSize {
+		return fmt.Errorf("plist: bplist: implausible object count %d", count)
+	}
+	return nil
+}
+
+func (p *bplistParser) parseDocument() (*plistValue, error) {
+	if len(p.data) < len(bplistMagic)+bplistTrailerSize {
+		return nil, fmt.Errorf("plist: truncated bplist document")
+	}
+
+	trailer := p.data[len(p.data)-bplistTrailerSize:]
+	p.offsetIntSize = int(trailer[6])
+	p.objectRefSize = int(trailer[7])
+	numObjectsU64 := binary.BigEndian.Uint64(trailer[8:16])
+	topObjectU64 := binary.BigEndian.Uint64(trailer[16:24])
+	offsetTableOffsetU64 := binary.BigEndian.Uint64(trailer[24:32])
+
+	if p.offsetIntSize < 1 || p.offsetIntSize > 8 {
+		return nil, fmt.Errorf("plist: bplist: invalid offset table int size %d", p.offsetIntSize)
+	}
+	if p.objectRefSize < 1 || p.objectRefSize > 8 {
+		return nil, fmt.Errorf("plist: bplist: invalid object ref size %d", p.objectRefSize)
+	}
+	// An object table can't have more entries than there are bytes in the
+	// document, so bounding against len(p.data) up front makes every later
+	// cast of a trailer-derived uint64 to int safe.
+	if numObjectsU64 > uint64(len(p.data)) {
+		return nil, fmt.Errorf("plist: bplist: implausible object count %d", numObjectsU64)
+	}
+	if offsetTableOffsetU64 > uint64(len(p.data)) {
+		return nil, fmt.Errorf("plist: bplist: offset table offset %d out of range", offsetTableOffsetU64)
+	}
+	p.numObjects = int(numObjectsU64)
+	offsetTableOffset := int(offsetTableOffsetU64)
+	if topObjectU64 >= numObjectsU64 {
+		return nil, fmt.Errorf("plist: bplist: top object index %d out of range", topObjectU64)
+	}
+	p.topObject = int(topObjectU64)
+
+	if err := p.checkCount(offsetTableOffset, p.numObjects, p.offsetIntSize); err != nil {
+		return nil, err
+	}
+
+	p.offsetTable = make([]uint64, p.numObjects)
+	pos := offsetTableOffset
+	for i := 0; i < p.numObjects; i++ {
+		p.offsetTable[i] = readUintBE(p.data[pos:pos+p.offsetIntSize], p.offsetIntSize)
+		pos += p.offsetIntSize
+	}
+
+	return p.parseObject(p.topObject)
+}
+
+// offsetOf returns the byte offset of object index as an int, after
+// checking it against the document size (the offset table stores it as a
+// uint64, which could otherwise overflow or go negative when cast).
+func (p *bplistParser) offsetOf(index int) (int, error) {
+	off := p.offsetTable[index]
+	if off > uint64(len(p.data)) {
+		return 0, fmt.Errorf("plist: bplist: object offset %d out of range", off)
+	}
+	return int(off), nil
+}
+
+func (p *bplistParser) parseObject(index int) (*plistValue, error) {
+	if index < 0 || index >= len(p.offsetTable) {
+		return nil, fmt.Errorf("plist: object reference %d out of range", index)
+	}
+	if len(p.parsing) >= maxBplistDepth {
+		return nil, fmt.Errorf("plist: bplist: object nesting exceeds %d levels", maxBplistDepth)
+	}
+	if p.parsing == nil {
+		p.parsing = make(map[int]bool)
+	}
+	if p.parsing[index] {
+		return nil, fmt.Errorf("plist: bplist: cyclic object reference at index %d", index)
+	}
+	p.parsing[index] = true
+	defer delete(p.parsing, index)
+
+	pos, err := p.offsetOf(index)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkBounds(pos, 1); err != nil {
+		return nil, err
+	}
+	marker := p.data[pos]
+	kindNibble := marker & 0xF0
+	sizeNibble := marker & 0x0F
+
+	switch kindNibble {
+	case 0x00:
+		switch marker {
+		case 0x08:
+			return &plistValue{Boolean, false}, nil
+		case 0x09:
+			return &plistValue{Boolean, true}, nil
+		default:
+			return nil, nil
+		}
+	case 0x10:
+		width := 1 << sizeNibble
+		if err := p.checkBounds(pos+1, width); err != nil {
+			return nil, err
+		}
+		n := readUintBE(p.data[pos+1:pos+1+width], width)
+		return &plistValue{Integer, signExtend(n, width)}, nil
+	case 0x20:
+		width := 1 << sizeNibble
+		if err := p.checkBounds(pos+1, width); err != nil {
+			return nil, err
+		}
+		bits := readUintBE(p.data[pos+1:pos+1+width], width)
+		var f float64
+		if width == 4 {
+			f = float64(math.Float32frombits(uint32(bits)))
+		} else {
+			f = math.Float64frombits(bits)
+		}
+		return &plistValue{Real, f}, nil
+	case 0x30:
+		width := 1 << sizeNibble
+		if err := p.checkBounds(pos+1, width); err != nil {
+			return nil, err
+		}
+		bits := readUintBE(p.data[pos+1:pos+1+width], width)
+		seconds := math.Float64frombits(bits)
+		t := macEpoch.Add(time.Duration(seconds * float64(time.Second)))
+		return &plistValue{Date, t}, nil
+	case 0x80:
+		width := int(sizeNibble) + 1
+		if err := p.checkBounds(pos+1, width); err != nil {
+			return nil, err
+		}
+		n := readUintBE(p.data[pos+1:pos+1+width], width)
+		return &plistValue{UIDKind, UID(n)}, nil
+	case 0x40:
+		length, contentStart, err := p.readLength(pos, sizeNibble)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkBounds(contentStart, length); err != nil {
+			return nil, err
+		}
+		b := make([]byte, length)
+		copy(b, p.data[contentStart:contentStart+length])
+		return &plistValue{Data, b}, nil
+	case 0x50:
+		length, contentStart, err := p.readLength(pos, sizeNibble)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkBounds(contentStart, length); err != nil {
+			return nil, err
+		}
+		return &plistValue{String, string(p.data[contentStart : contentStart+length])}, nil
+	case 0x60:
+		length, contentStart, err := p.readLength(pos, sizeNibble)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkCount(contentStart, length, 2); err != nil {
+			return nil, err
+		}
+		units := make([]uint16, length)
+		for i := 0; i < length; i++ {
+			units[i] = uint16(readUintBE(p.data[contentStart+i*2:contentStart+i*2+2], 2))
+		}
+		return &plistValue{String, string(utf16.Decode(units))}, nil
+	case 0xA0:
+		count, contentStart, err := p.readLength(pos, sizeNibble)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkCount(contentStart, count, p.objectRefSize); err != nil {
+			return nil, err
+		}
+		subvalues := make([]*plistValue, count)
+		for i := 0; i < count; i++ {
+			ref := int(readUintBE(p.data[contentStart+i*p.objectRefSize:contentStart+(i+1)*p.objectRefSize], p.objectRefSize))
+			sub, err := p.parseObject(ref)
+			if err != nil {
+				return nil, err
+			}
+			subvalues[i] = sub
+		}
+		return &plistValue{Array, subvalues}, nil
+	case 0xD0:
+		count, contentStart, err := p.readLength(pos, sizeNibble)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkCount(contentStart, count, 2*p.objectRefSize); err != nil {
+			return nil, err
+		}
+		pd := &plistDict{keys: make([]string, count), values: make([]*plistValue, count)}
+		keyRefsStart := contentStart
+		valRefsStart := contentStart + count*p.objectRefSize
+		for i := 0; i < count; i++ {
+			keyRef := int(readUintBE(p.data[keyRefsStart+i*p.objectRefSize:keyRefsStart+(i+1)*p.objectRefSize], p.objectRefSize))
+			valRef := int(readUintBE(p.data[valRefsStart+i*p.objectRefSize:valRefsStart+(i+1)*p.objectRefSize], p.objectRefSize))
+			keyPval, err := p.parseObject(keyRef)
+			if err != nil {
+				return nil, err
+			}
+			valPval, err := p.parseObject(valRef)
+			if err != nil {
+				return nil, err
+			}
+			if keyPval == nil {
+				return nil, fmt.Errorf("plist: bplist: dictionary key object is not a string")
+			}
+			key, ok := keyPval.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("plist: bplist: dictionary key object is not a string")
+			}
+			pd.keys[i] = key
+			pd.values[i] = valPval
+		}
+		return &plistValue{Dictionary, pd}, nil
+	default:
+		return nil, fmt.Errorf("plist: unknown bplist object marker 0x%02x", marker)
+	}
+}
+
+// readLength decodes the count encoded in a marker byte's low nibble,
+// following the 0xNF + inline-int-object convention for values >= 15, and
+// returns the count along with the offset of the content following it. It
+// validates every byte it reads against the document size and rejects a
+// decoded count that couldn't possibly fit in the remaining document.
+func (p *bplistParser) readLength(markerPos int, sizeNibble byte) (int, int, error) {
+	if sizeNibble != 0x0F {
+		return int(sizeNibble), markerPos + 1, nil
+	}
+	intMarkerPos := markerPos + 1
+	if err := p.checkBounds(intMarkerPos, 1); err != nil {
+		return 0, 0, err
+	}
+	width := 1 << (p.data[intMarkerPos] & 0x0F)
+	contentPos := intMarkerPos + 1
+	if err := p.checkBounds(contentPos, width); err != nil {
+		return 0, 0, err
+	}
+	n := readUintBE(p.data[contentPos:contentPos+width], width)
+	if n > uint64(len(p.data)) {
+		return 0, 0, fmt.Errorf("plist: bplist: implausible length %d", n)
+	}
+	return int(n), contentPos + width, nil
+}
+
+// readUintBE reads width bytes (1-8, not necessarily a power of two, as
+// CF$UID references allow) from b as a big-endian unsigned integer.
+func readUintBE(b []byte, width int) uint64 {
+	var n uint64
+	for i := 0; i < width; i++ {
+		n = n<<8 | uint64(b[i])
+	}
+	return n
+}
+
+// signExtend interprets n as a two's-complement integer of the given byte
+// width and returns its value sign-extended into an int64.
+func signExtend(n uint64, width int) int64 {
+	switch width {
+	case 1:
+		return int64(int8(n))
+	case 2:
+		return int64(int16(n))
+	case 4:
+		return int64(int32(n))
+	default:
+		return int64(n)
+	}
+}
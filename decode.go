@@ -0,0 +1,217 @@
+package plist
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// plistValueToValue stores the value represented by pval into v, allocating
+// and dereferencing pointers and interfaces as necessary. It is the
+// decode-side counterpart to valueToPlistValue.
+func plistValueToValue(pval *plistValue, v reflect.Value) error {
+	if pval == nil {
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return plistValueToValue(pval, v.Elem())
+	}
+
+	if v.CanAddr() {
+		pv := v.Addr()
+		if pv.CanInterface() && pv.Type().Implements(unmarshalerType) {
+			iface, err := plistValueToInterface(pval)
+			if err != nil {
+				return err
+			}
+			return pv.Interface().(Unmarshaler).UnmarshalPlist(iface)
+		}
+	}
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		iface, err := plistValueToInterface(pval)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(iface))
+		return nil
+	}
+
+	switch pval.kind {
+	case String:
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("plist: cannot decode string into %v", v.Type())
+		}
+		v.SetString(pval.value.(string))
+	case Integer:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v.SetInt(toInt64(pval.value))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v.SetUint(toUint64(pval.value))
+		default:
+			return fmt.Errorf("plist: cannot decode integer into %v", v.Type())
+		}
+	case Real:
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return fmt.Errorf("plist: cannot decode real into %v", v.Type())
+		}
+		v.SetFloat(pval.value.(float64))
+	case Boolean:
+		if v.Kind() != reflect.Bool {
+			return fmt.Errorf("plist: cannot decode boolean into %v", v.Type())
+		}
+		v.SetBool(pval.value.(bool))
+	case Data:
+		b := pval.value.([]byte)
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(b)
+			return nil
+		}
+		return fmt.Errorf("plist: cannot decode data into %v", v.Type())
+	case Array:
+		subvalues := pval.value.([]*plistValue)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return fmt.Errorf("plist: cannot decode array into %v", v.Type())
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(subvalues), len(subvalues)))
+		}
+		for i, sub := range subvalues {
+			if i >= v.Len() {
+				break
+			}
+			if err := plistValueToValue(sub, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case Date:
+		t := pval.value.(time.Time)
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+		if v.CanAddr() {
+			pv := v.Addr()
+			if pv.CanInterface() && pv.Type().Implements(textUnmarshalerType) {
+				return pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(t.Format(time.RFC3339)))
+			}
+		}
+		return fmt.Errorf("plist: cannot decode date into %v", v.Type())
+	case UIDKind:
+		if v.Type() != uidType {
+			return fmt.Errorf("plist: cannot decode UID into %v", v.Type())
+		}
+		v.SetUint(uint64(pval.value.(UID)))
+		return nil
+	case Dictionary:
+		return dictionaryToValue(pval, v)
+	default:
+		return &UnknownTypeError{Type: v.Type()}
+	}
+	return nil
+}
+
+func dictionaryToValue(pval *plistValue, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		tinfo, err := getTypeInfo(v.Type())
+		if err != nil {
+			return err
+		}
+		keys, subvalues := dictionaryEntries(pval)
+		for i, key := range keys {
+			for _, finfo := range tinfo.fields {
+				if finfo.name == key {
+					if err := plistValueToValue(subvalues[i], finfo.value(v)); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &UnknownTypeError{Type: v.Type()}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		keys, subvalues := dictionaryEntries(pval)
+		for i, key := range keys {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := plistValueToValue(subvalues[i], elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	default:
+		return fmt.Errorf("plist: cannot decode dictionary into %v", v.Type())
+	}
+}
+
+// plistValueToInterface converts pval into the generic Go representation
+// used when decoding into an interface{} (map[string]interface{},
+// []interface{}, string, int64, uint64, float64, bool, []byte, time.Time,
+// UID).
+func plistValueToInterface(pval *plistValue) (interface{}, error) {
+	switch pval.kind {
+	case String, Integer, Real, Boolean, Data, Date, UIDKind:
+		return pval.value, nil
+	case Array:
+		subvalues := pval.value.([]*plistValue)
+		out := make([]interface{}, len(subvalues))
+		for i, sub := range subvalues {
+			v, err := plistValueToInterface(sub)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case Dictionary:
+		keys, subvalues := dictionaryEntries(pval)
+		out := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			v, err := plistValueToInterface(subvalues[i])
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		}
+		return out, nil
+	}
+	return nil, &UnknownTypeError{}
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
+
+func toUint64(v interface{}) uint64 {
+	switch n := v.(type) {
+	case int64:
+		return uint64(n)
+	case uint64:
+		return n
+	}
+	return 0
+}
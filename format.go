@@ -0,0 +1,32 @@
+package plist
+
+import "bytes"
+
+// Format identifies one of the wire formats this package can produce or
+// consume.
+type Format int
+
+const (
+	// FormatXML is the classic Apple XML plist format (<?xml ...?><plist>...).
+	FormatXML Format = iota
+	// FormatBinary is Apple's "bplist00" binary format.
+	FormatBinary
+	// FormatOpenStep is the classic NeXT/OpenStep ASCII plist format, as
+	// produced by `plutil -convert openstep` and consumed by GNUstep.
+	FormatOpenStep
+)
+
+var bplistMagic = []byte("bplist00")
+
+// detectFormat sniffs the wire format of a serialized plist document from
+// its leading bytes.
+func detectFormat(data []byte) Format {
+	if bytes.HasPrefix(data, bplistMagic) {
+		return FormatBinary
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<plist")) {
+		return FormatXML
+	}
+	return FormatOpenStep
+}
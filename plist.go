@@ -0,0 +1,93 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+)
+
+// An Encoder writes a plist document, in a chosen Format, to an output
+// stream.
+type Encoder struct {
+	w      io.Writer
+	format Format
+}
+
+// NewEncoder returns a new Encoder that writes to w using format.
+func NewEncoder(w io.Writer, format Format) *Encoder {
+	return &Encoder{w: w, format: format}
+}
+
+// Encode marshals v into a plist document and writes it to the Encoder's
+// writer.
+func (e *Encoder) Encode(v interface{}) error {
+	pval, err := valueToPlistValue(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+
+	switch e.format {
+	case FormatBinary:
+		return newBplistGenerator(e.w).generateDocument(pval)
+	case FormatOpenStep:
+		return newTextGenerator(e.w).generateDocument(pval)
+	default:
+		return newXMLGenerator(e.w).generateDocument(pval)
+	}
+}
+
+// A Decoder reads a plist document from an input stream, auto-detecting
+// its Format.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the whole of the Decoder's stream, auto-detects its format,
+// and stores the result into the value pointed to by v.
+func (d *Decoder) Decode(v interface{}) error {
+	data, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+
+	var pval *plistValue
+	switch detectFormat(data) {
+	case FormatBinary:
+		pval, err = newBplistParser(data).parseDocument()
+	case FormatOpenStep:
+		pval, err = newTextParser(data).parseDocument()
+	default:
+		pval, err = newXMLParser(data).parseDocument()
+	}
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("plist: Decode requires a non-nil pointer, got %T", v)
+	}
+	return plistValueToValue(pval, rv.Elem())
+}
+
+// Marshal returns the XML plist encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, FormatXML).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses a plist document, in any format this package supports,
+// and stores the result into the value pointed to by v.
+func Unmarshal(data []byte, v interface{}) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,245 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPerson struct {
+	Zodiac string
+	Age    int
+	Name   string
+	Email  string `plist:"Email,omitempty"`
+}
+
+func roundTrip(t *testing.T, format Format, in interface{}, out interface{}) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, format).Encode(in); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if err := NewDecoder(&buf).Decode(out); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+}
+
+func TestRoundTripKinds(t *testing.T) {
+	in := map[string]interface{}{
+		"string": "hello world",
+		"int":    int64(42),
+		"real":   3.5,
+		"bool":   true,
+		"data":   []byte{0xDE, 0xAD, 0xBE, 0xEF},
+		"array":  []interface{}{"a", "b", "c"},
+	}
+
+	for _, format := range []Format{FormatXML, FormatBinary, FormatOpenStep} {
+		var out map[string]interface{}
+		roundTrip(t, format, in, &out)
+
+		if out["string"] != in["string"] {
+			t.Errorf("format %v: string = %v, want %v", format, out["string"], in["string"])
+		}
+		if out["int"] != in["int"] {
+			t.Errorf("format %v: int = %v, want %v", format, out["int"], in["int"])
+		}
+		if out["real"] != in["real"] {
+			t.Errorf("format %v: real = %v, want %v", format, out["real"], in["real"])
+		}
+		if out["bool"] != in["bool"] {
+			t.Errorf("format %v: bool = %v, want %v", format, out["bool"], in["bool"])
+		}
+		if !bytes.Equal(out["data"].([]byte), in["data"].([]byte)) {
+			t.Errorf("format %v: data = %v, want %v", format, out["data"], in["data"])
+		}
+		if !reflect.DeepEqual(out["array"], in["array"]) {
+			t.Errorf("format %v: array = %v, want %v", format, out["array"], in["array"])
+		}
+	}
+}
+
+func TestRoundTripDate(t *testing.T) {
+	in := time.Date(2026, 7, 27, 12, 30, 0, 0, time.UTC)
+
+	for _, format := range []Format{FormatXML, FormatBinary, FormatOpenStep} {
+		var out time.Time
+		roundTrip(t, format, in, &out)
+		if !out.Equal(in) {
+			t.Errorf("format %v: date = %v, want %v", format, out, in)
+		}
+	}
+}
+
+func TestRoundTripUID(t *testing.T) {
+	in := UID(123456789)
+
+	for _, format := range []Format{FormatXML, FormatBinary, FormatOpenStep} {
+		var out UID
+		roundTrip(t, format, in, &out)
+		if out != in {
+			t.Errorf("format %v: UID = %v, want %v", format, out, in)
+		}
+	}
+}
+
+func TestRoundTripStruct(t *testing.T) {
+	in := testPerson{Zodiac: "Capricorn", Age: 30, Name: "Ada"}
+
+	for _, format := range []Format{FormatXML, FormatBinary, FormatOpenStep} {
+		var out testPerson
+		roundTrip(t, format, in, &out)
+		if out != in {
+			t.Errorf("format %v: struct = %+v, want %+v", format, out, in)
+		}
+	}
+}
+
+func TestStructFieldsKeepDeclarationOrder(t *testing.T) {
+	in := testPerson{Zodiac: "Capricorn", Age: 30, Name: "Ada"}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	xml := string(data)
+	zodiacIdx := strings.Index(xml, "<key>Zodiac</key>")
+	ageIdx := strings.Index(xml, "<key>Age</key>")
+	nameIdx := strings.Index(xml, "<key>Name</key>")
+	if zodiacIdx < 0 || ageIdx < 0 || nameIdx < 0 {
+		t.Fatalf("expected all three keys present in %s", xml)
+	}
+	if !(zodiacIdx < ageIdx && ageIdx < nameIdx) {
+		t.Errorf("keys out of declaration order in %s", xml)
+	}
+
+	if strings.Contains(xml, "Email") {
+		t.Errorf("omitempty field Email should have been omitted: %s", xml)
+	}
+}
+
+func TestMapKeysSortedDeterministically(t *testing.T) {
+	in := map[string]string{"zebra": "1", "apple": "2", "mango": "3"}
+
+	data1, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	data2, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(data1, data2) {
+		t.Fatalf("two encodes of the same map produced different output")
+	}
+
+	xml := string(data1)
+	appleIdx := strings.Index(xml, "<key>apple</key>")
+	mangoIdx := strings.Index(xml, "<key>mango</key>")
+	zebraIdx := strings.Index(xml, "<key>zebra</key>")
+	if !(appleIdx < mangoIdx && mangoIdx < zebraIdx) {
+		t.Errorf("map keys not sorted lexicographically in %s", xml)
+	}
+}
+
+type upperCaser string
+
+func (u upperCaser) MarshalPlist() (interface{}, error) {
+	return strings.ToUpper(string(u)), nil
+}
+
+func (u *upperCaser) UnmarshalPlist(v interface{}) error {
+	*u = upperCaser(v.(string))
+	return nil
+}
+
+func TestMarshalerUnmarshaler(t *testing.T) {
+	in := upperCaser("hello")
+
+	for _, format := range []Format{FormatXML, FormatBinary, FormatOpenStep} {
+		var out upperCaser
+		roundTrip(t, format, in, &out)
+		if out != "HELLO" {
+			t.Errorf("format %v: got %q, want %q", format, out, "HELLO")
+		}
+	}
+}
+
+// decodeBplistBytes feeds data directly to the bplist parser, bypassing
+// format sniffing, so malformed documents that are too short to even carry
+// the "bplist00" magic can still be exercised.
+func decodeBplistBytes(data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	_, err = newBplistParser(data).parseDocument()
+	return err
+}
+
+// TestBplistTruncatedInput feeds the parser documents truncated at every
+// possible length, including down to zero bytes. A well-formed bplist
+// document from the generator below has valid offsets and lengths, but any
+// truncation can leave a dangling offset, length, or trailer field -
+// parseDocument must report an error rather than panic.
+func TestBplistTruncatedInput(t *testing.T) {
+	var buf bytes.Buffer
+	in := map[string]interface{}{
+		"string": "hello world",
+		"int":    int64(42),
+		"nested": map[string]interface{}{"a": []interface{}{"x", "y", int64(3)}},
+		"data":   []byte{0xDE, 0xAD, 0xBE, 0xEF},
+	}
+	if err := NewEncoder(&buf, FormatBinary).Encode(in); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	full := buf.Bytes()
+
+	for n := 0; n <= len(full); n++ {
+		if err := decodeBplistBytes(full[:n]); err != nil && strings.HasPrefix(err.Error(), "panic:") {
+			t.Fatalf("truncating to %d bytes panicked: %v", n, err)
+		}
+	}
+}
+
+// TestBplistCorruptInput throws random and adversarially tweaked bytes at
+// the parser and asserts it only ever returns an error, never panics.
+func TestBplistCorruptInput(t *testing.T) {
+	var buf bytes.Buffer
+	in := map[string]interface{}{
+		"string": "hello world",
+		"array":  []interface{}{"a", "b", int64(7)},
+	}
+	if err := NewEncoder(&buf, FormatBinary).Encode(in); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	valid := buf.Bytes()
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		corrupt := append([]byte(nil), valid...)
+		for j := 0; j < 1+rng.Intn(8); j++ {
+			corrupt[rng.Intn(len(corrupt))] = byte(rng.Intn(256))
+		}
+		if err := decodeBplistBytes(corrupt); err != nil && strings.HasPrefix(err.Error(), "panic:") {
+			t.Fatalf("iteration %d: corrupt input panicked: %v\ninput: %x", i, err, corrupt)
+		}
+	}
+
+	// A hand-crafted string object whose extended length marker claims a
+	// length far larger than the document.
+	inflated := []byte("bplist00")
+	inflated = append(inflated, 0x5F, 0x1F) // string marker with extended-length int, width 2 bytes (nibble 1 -> 1<<1=2)
+	inflated = append(inflated, 0x7F, 0xFF) // length = 32767, nowhere near available data
+	inflated = append(inflated, make([]byte, 32)...)
+	if err := decodeBplistBytes(inflated); err != nil && strings.HasPrefix(err.Error(), "panic:") {
+		t.Fatalf("inflated-length input panicked: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package plist
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// gnustepDateLayout is the format used by GNUstep's `<*D...>` date
+// extension: "YYYY-MM-DD HH:MM:SS +ZZZZ".
+const gnustepDateLayout = "2006-01-02 15:04:05 -0700"
+
+// textGenerator renders a plistValue tree as an OpenStep/GNUstep ASCII
+// property list, using the GNUstep `<*I...>`/`<*R...>`/`<*B.>`/`<*D...>`
+// typed extensions so integers, reals, booleans, and dates survive a round
+// trip instead of degrading to plain strings.
+type textGenerator struct {
+	w     io.Writer
+	depth int
+}
+
+func newTextGenerator(w io.Writer) *textGenerator {
+	return &textGenerator{w: w}
+}
+
+func (g *textGenerator) generateDocument(root *plistValue) error {
+	if err := g.writeValue(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(g.w, "\n")
+	return err
+}
+
+func (g *textGenerator) indent() string {
+	return strings.Repeat("    ", g.depth)
+}
+
+func (g *textGenerator) writeValue(pval *plistValue) error {
+	if pval == nil {
+		_, err := io.WriteString(g.w, `""`)
+		return err
+	}
+
+	switch pval.kind {
+	case Dictionary:
+		return g.writeDict(pval)
+	case Array:
+		return g.writeArray(pval)
+	case String:
+		_, err := io.WriteString(g.w, quoteTextString(pval.value.(string)))
+		return err
+	case Integer:
+		_, err := fmt.Fprintf(g.w, "<*I%s>", formatPlistInteger(pval.value))
+		return err
+	case Real:
+		_, err := fmt.Fprintf(g.w, "<*R%s>", formatPlistReal(pval.value.(float64)))
+		return err
+	case Boolean:
+		tag := "N"
+		if pval.value.(bool) {
+			tag = "Y"
+		}
+		_, err := fmt.Fprintf(g.w, "<*B%s>", tag)
+		return err
+	case Data:
+		_, err := io.WriteString(g.w, "<"+hexEncode(pval.value.([]byte))+">")
+		return err
+	case Date:
+		_, err := fmt.Fprintf(g.w, "<*D%s>", pval.value.(time.Time).UTC().Format(gnustepDateLayout))
+		return err
+	case UIDKind:
+		// GNUstep has no native UID extension; mirror the XML format's
+		// <dict><key>CF$UID</key><integer>N</integer></dict> convention
+		// so the two text-based formats round-trip the same way.
+		uidDict := &plistValue{Dictionary, &plistDict{
+			keys:   []string{"CF$UID"},
+			values: []*plistValue{{Integer, int64(pval.value.(UID))}},
+		}}
+		return g.writeDict(uidDict)
+	default:
+		return unknownKindError(pval)
+	}
+}
+
+func (g *textGenerator) writeDict(pval *plistValue) error {
+	keys, values := dictionaryEntries(pval)
+	if _, err := io.WriteString(g.w, "{\n"); err != nil {
+		return err
+	}
+	g.depth++
+	for i, key := range keys {
+		if _, err := io.WriteString(g.w, g.indent()+quoteTextString(key)+" = "); err != nil {
+			return err
+		}
+		if err := g.writeValue(values[i]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(g.w, ";\n"); err != nil {
+			return err
+		}
+	}
+	g.depth--
+	_, err := io.WriteString(g.w, g.indent()+"}")
+	return err
+}
+
+func (g *textGenerator) writeArray(pval *plistValue) error {
+	subvalues := pval.value.([]*plistValue)
+	if _, err := io.WriteString(g.w, "(\n"); err != nil {
+		return err
+	}
+	g.depth++
+	for i, sub := range subvalues {
+		if _, err := io.WriteString(g.w, g.indent()); err != nil {
+			return err
+		}
+		if err := g.writeValue(sub); err != nil {
+			return err
+		}
+		sep := ",\n"
+		if i == len(subvalues)-1 {
+			sep = "\n"
+		}
+		if _, err := io.WriteString(g.w, sep); err != nil {
+			return err
+		}
+	}
+	g.depth--
+	_, err := io.WriteString(g.w, g.indent()+")")
+	return err
+}
+
+// isPlainTextIdentifier reports whether s can be written unquoted in
+// OpenStep syntax: non-empty and made up solely of letters, digits, and
+// the punctuation OpenStep treats as part of a bare identifier.
+func isPlainTextIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '$' || r == '/' || r == ':' || r == '.' || r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func quoteTextString(s string) string {
+	if isPlainTextIdentifier(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func hexEncode(b []byte) string {
+	const digits = "0123456789abcdef"
+	out := make([]byte, len(b)*2)
+	for i, c := range b {
+		out[i*2] = digits[c>>4]
+		out[i*2+1] = digits[c&0xF]
+	}
+	return string(out)
+}
@@ -0,0 +1,300 @@
+package plist
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// textParser builds a plistValue tree from an OpenStep/GNUstep ASCII
+// property list, including the GNUstep `<*I...>`/`<*R...>`/`<*B.>`/
+// `<*D...>` typed extensions.
+type textParser struct {
+	data []byte
+	pos  int
+}
+
+func newTextParser(data []byte) *textParser {
+	return &textParser{data: data}
+}
+
+func (p *textParser) parseDocument() (*plistValue, error) {
+	p.skipWhitespace()
+	pval, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	return pval, nil
+}
+
+func (p *textParser) skipWhitespace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '/':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '*':
+			p.pos += 2
+			for p.pos+1 < len(p.data) && !(p.data[p.pos] == '*' && p.data[p.pos+1] == '/') {
+				p.pos++
+			}
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("plist: text format: "+format, args...)
+}
+
+func (p *textParser) parseValue() (*plistValue, error) {
+	p.skipWhitespace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of document")
+	}
+
+	switch p.data[p.pos] {
+	case '{':
+		return p.parseDict()
+	case '(':
+		return p.parseArray()
+	case '"':
+		s, err := p.parseQuotedString()
+		return &plistValue{String, s}, err
+	case '<':
+		return p.parseAngle()
+	default:
+		s, err := p.parseBareString()
+		return &plistValue{String, s}, err
+	}
+}
+
+func (p *textParser) parseDict() (*plistValue, error) {
+	p.pos++ // '{'
+	pd := &plistDict{}
+	for {
+		p.skipWhitespace()
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated dictionary")
+		}
+		if p.data[p.pos] == '}' {
+			p.pos++
+			if len(pd.keys) == 1 && pd.keys[0] == "CF$UID" && pd.values[0].kind == Integer {
+				return &plistValue{UIDKind, UID(toUint64(pd.values[0].value))}, nil
+			}
+			return &plistValue{Dictionary, pd}, nil
+		}
+
+		keyVal, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keyVal.value.(string)
+		if !ok {
+			return nil, p.errorf("dictionary keys must be strings")
+		}
+
+		p.skipWhitespace()
+		if p.pos >= len(p.data) || p.data[p.pos] != '=' {
+			return nil, p.errorf("expected '=' after dictionary key %q", key)
+		}
+		p.pos++
+
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		pd.keys = append(pd.keys, key)
+		pd.values = append(pd.values, val)
+
+		p.skipWhitespace()
+		if p.pos < len(p.data) && p.data[p.pos] == ';' {
+			p.pos++
+		}
+	}
+}
+
+func (p *textParser) parseArray() (*plistValue, error) {
+	p.pos++ // '('
+	var subvalues []*plistValue
+	for {
+		p.skipWhitespace()
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.data[p.pos] == ')' {
+			p.pos++
+			return &plistValue{Array, subvalues}, nil
+		}
+
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		subvalues = append(subvalues, val)
+
+		p.skipWhitespace()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+		}
+	}
+}
+
+func (p *textParser) parseQuotedString() (string, error) {
+	p.pos++ // opening '"'
+	var out []byte
+	for {
+		if p.pos >= len(p.data) {
+			return "", p.errorf("unterminated quoted string")
+		}
+		c := p.data[p.pos]
+		if c == '"' {
+			p.pos++
+			return string(out), nil
+		}
+		if c == '\\' {
+			p.pos++
+			if p.pos >= len(p.data) {
+				return "", p.errorf("unterminated escape in quoted string")
+			}
+			switch p.data[p.pos] {
+			case 'n':
+				out = append(out, '\n')
+			case 't':
+				out = append(out, '\t')
+			case 'r':
+				out = append(out, '\r')
+			case '"':
+				out = append(out, '"')
+			case '\\':
+				out = append(out, '\\')
+			default:
+				out = append(out, p.data[p.pos])
+			}
+			p.pos++
+			continue
+		}
+		out = append(out, c)
+		p.pos++
+	}
+}
+
+func isBareStringByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	case c == '_' || c == '$' || c == '/' || c == ':' || c == '.' || c == '-':
+		return true
+	}
+	return false
+}
+
+func (p *textParser) parseBareString() (string, error) {
+	start := p.pos
+	for p.pos < len(p.data) && isBareStringByte(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos >= len(p.data) {
+			return "", p.errorf("unexpected end of document")
+		}
+		return "", p.errorf("unexpected character %q", p.data[p.pos])
+	}
+	return string(p.data[start:p.pos]), nil
+}
+
+// parseAngle parses either a `<hexdata>` blob or a GNUstep
+// `<*I.../*R.../*BY/*BN/*D...>` typed extension.
+func (p *textParser) parseAngle() (*plistValue, error) {
+	p.pos++ // '<'
+	if p.pos < len(p.data) && p.data[p.pos] == '*' {
+		return p.parseTypedExtension()
+	}
+
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		p.pos++
+	}
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unterminated data block")
+	}
+	b, err := hexDecode(removeWhitespace(string(p.data[start:p.pos])))
+	p.pos++ // '>'
+	return &plistValue{Data, b}, err
+}
+
+func (p *textParser) parseTypedExtension() (*plistValue, error) {
+	p.pos++ // '*'
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unterminated typed extension")
+	}
+	typeChar := p.data[p.pos]
+	p.pos++
+
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		p.pos++
+	}
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unterminated typed extension")
+	}
+	content := string(p.data[start:p.pos])
+	p.pos++ // '>'
+
+	switch typeChar {
+	case 'I':
+		if n, err := strconv.ParseInt(content, 10, 64); err == nil {
+			return &plistValue{Integer, n}, nil
+		}
+		n, err := strconv.ParseUint(content, 10, 64)
+		return &plistValue{Integer, n}, err
+	case 'R':
+		f, err := strconv.ParseFloat(content, 64)
+		return &plistValue{Real, f}, err
+	case 'B':
+		return &plistValue{Boolean, content == "Y"}, nil
+	case 'D':
+		t, err := time.Parse(gnustepDateLayout, content)
+		return &plistValue{Date, t}, err
+	default:
+		return nil, p.errorf("unknown typed extension <*%c...>", typeChar)
+	}
+}
+
+func hexDecode(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("plist: text format: odd-length data block")
+	}
+	out := make([]byte, len(s)/2)
+	for i := 0; i < len(out); i++ {
+		hi, err := hexNibble(s[i*2])
+		if err != nil {
+			return nil, err
+		}
+		lo, err := hexNibble(s[i*2+1])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = hi<<4 | lo
+	}
+	return out, nil
+}
+
+func hexNibble(c byte) (byte, error) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', nil
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, nil
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, nil
+	}
+	return 0, fmt.Errorf("plist: text format: invalid hex digit %q", c)
+}
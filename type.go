@@ -2,8 +2,9 @@ package plist
 
 import (
 	"encoding"
-	"errors"
 	"reflect"
+	"sort"
+	"time"
 )
 
 type plistKind uint
@@ -17,13 +18,50 @@ const (
 	Real
 	Boolean
 	Data
+	Date
+	UIDKind
 )
 
+// UID represents a CF$UID reference, as found in NSKeyedArchiver output
+// (keyed archives, .mobileprovision files, iTunes backups). It is
+// distinct from a plain Integer so that encoders and decoders can
+// preserve the distinction Apple's tooling makes between the two.
+type UID uint64
+
 type plistValue struct {
 	kind  plistKind
 	value interface{}
 }
 
+// plistDict is the Dictionary payload: parallel key/value slices in a
+// deterministic order, rather than a Go map, so that two encodes of
+// equivalent data always produce byte-identical output. Struct fields
+// keep their declaration order (see structToPlistValue); map keys are
+// sorted lexicographically (see the reflect.Map case of
+// valueToPlistValue), matching the order `plutil -convert xml1` produces.
+type plistDict struct {
+	keys   []string
+	values []*plistValue
+}
+
+// Marshaler is implemented by types that want to control their own plist
+// representation. MarshalPlist returns a Go value (which may itself
+// implement Marshaler) that is recursively encoded in the implementing
+// value's place, mirroring the extensibility encoding/json and
+// encoding/gob provide via their own Marshaler interfaces.
+type Marshaler interface {
+	MarshalPlist() (interface{}, error)
+}
+
+// Unmarshaler is implemented by types that want to control their own
+// decoding from a plist. UnmarshalPlist receives the already-decoded tree
+// (a map[string]interface{}, []interface{}, string, int64, uint64,
+// float64, bool, []byte, time.Time, or UID) for the value in the
+// document.
+type Unmarshaler interface {
+	UnmarshalPlist(interface{}) error
+}
+
 type UnknownTypeError struct {
 	Type reflect.Type
 }
@@ -32,6 +70,18 @@ func (u *UnknownTypeError) Error() string {
 	return "Unknown type " + u.Type.String()
 }
 
+// unknownKindError builds an UnknownTypeError for a plistValue whose kind
+// a generator doesn't know how to render. It always sets Type from the
+// plistValue's stored payload (falling back to the plistKind itself for
+// the nil-payload case) so Error() never dereferences a nil Type, unlike
+// a bare &UnknownTypeError{}.
+func unknownKindError(pval *plistValue) *UnknownTypeError {
+	if pval.value != nil {
+		return &UnknownTypeError{Type: reflect.TypeOf(pval.value)}
+	}
+	return &UnknownTypeError{Type: reflect.TypeOf(pval.kind)}
+}
+
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -51,9 +101,20 @@ func isEmptyValue(v reflect.Value) bool {
 }
 
 var (
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
 	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	uidType           = reflect.TypeOf(UID(0))
 )
 
+func marshalerToPlistValue(marshalable Marshaler) (*plistValue, error) {
+	v, err := marshalable.MarshalPlist()
+	if err != nil {
+		return nil, err
+	}
+	return valueToPlistValue(reflect.ValueOf(v))
+}
+
 func stringMarshalableToPlistValue(marshalable encoding.TextMarshaler) (*plistValue, error) {
 	s, err := marshalable.MarshalText()
 	if err != nil {
@@ -65,7 +126,7 @@ func stringMarshalableToPlistValue(marshalable encoding.TextMarshaler) (*plistVa
 func structToPlistValue(typ reflect.Type, val reflect.Value) (*plistValue, error) {
 	tinfo, _ := getTypeInfo(typ)
 
-	subvalues := make(map[string]*plistValue, len(tinfo.fields))
+	pd := &plistDict{keys: make([]string, 0, len(tinfo.fields)), values: make([]*plistValue, 0, len(tinfo.fields))}
 	for _, finfo := range tinfo.fields {
 		value := finfo.value(val)
 		if !value.IsValid() || finfo.omitEmpty && isEmptyValue(value) {
@@ -76,10 +137,35 @@ func structToPlistValue(typ reflect.Type, val reflect.Value) (*plistValue, error
 			return nil, err
 		}
 
-		subvalues[finfo.name] = v
+		pd.keys = append(pd.keys, finfo.name)
+		pd.values = append(pd.values, v)
+	}
+
+	return &plistValue{Dictionary, pd}, nil
+}
+
+// dictionaryEntries returns the keys and values of a Dictionary plistValue
+// as parallel slices, in the order recorded in its plistDict.
+func dictionaryEntries(pval *plistValue) ([]string, []*plistValue) {
+	pd := pval.value.(*plistDict)
+	return pd.keys, pd.values
+}
+
+// newPlistDict builds a plistDict from an unordered set of key/value
+// pairs, sorting the keys lexicographically. Used where the source data
+// (a Go map) carries no inherent order of its own.
+func newPlistDict(unordered map[string]*plistValue) *plistDict {
+	keys := make([]string, 0, len(unordered))
+	for k := range unordered {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	return &plistValue{Dictionary, subvalues}, nil
+	values := make([]*plistValue, len(keys))
+	for i, k := range keys {
+		values[i] = unordered[k]
+	}
+	return &plistDict{keys: keys, values: values}
 }
 
 func valueToPlistValue(val reflect.Value) (*plistValue, error) {
@@ -89,6 +175,29 @@ func valueToPlistValue(val reflect.Value) (*plistValue, error) {
 		return nil, nil
 	}
 
+	// Check for the Marshaler interface first so custom types can always
+	// override the built-in encodings below, including the time.Time and
+	// UID special cases.
+	if val.CanInterface() && typ.Implements(marshalerType) {
+		return marshalerToPlistValue(val.Interface().(Marshaler))
+	}
+	if val.CanAddr() {
+		pv := val.Addr()
+		if pv.CanInterface() && pv.Type().Implements(marshalerType) {
+			return marshalerToPlistValue(pv.Interface().(Marshaler))
+		}
+	}
+
+	// time.Time gets its own plistKind so it round-trips as a plist <date>
+	// rather than being flattened into a string by the TextMarshaler case
+	// below.
+	if val.CanInterface() && typ == timeType {
+		return &plistValue{Date, val.Interface().(time.Time)}, nil
+	}
+	if val.CanInterface() && typ == uidType {
+		return &plistValue{UIDKind, val.Interface().(UID)}, nil
+	}
+
 	// Check for text marshaler.
 	if val.CanInterface() && typ.Implements(textMarshalerType) {
 		return stringMarshalableToPlistValue(val.Interface().(encoding.TextMarshaler))
@@ -156,9 +265,8 @@ func valueToPlistValue(val reflect.Value) (*plistValue, error) {
 
 			subvalues[keyv.String()] = v
 		}
-		return &plistValue{Dictionary, subvalues}, nil
+		return &plistValue{Dictionary, newPlistDict(subvalues)}, nil
 	default:
 		return nil, &UnknownTypeError{Type: typ}
 	}
-	return nil, errors.New("Wat")
-}
\ No newline at end of file
+}
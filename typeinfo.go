@@ -0,0 +1,80 @@
+package plist
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldInfo describes how a single struct field is represented in a
+// plist dictionary.
+type fieldInfo struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// value returns the reflect.Value of this field within val, a
+// reflect.Value of the struct fieldInfo was computed for.
+func (f fieldInfo) value(val reflect.Value) reflect.Value {
+	return val.FieldByIndex(f.index)
+}
+
+// typeInfo is the cached, declaration-ordered field list for a struct
+// type, used by structToPlistValue and dictionaryToValue so encode and
+// decode agree on field names and order.
+type typeInfo struct {
+	fields []fieldInfo
+}
+
+var typeInfoCache sync.Map // map[reflect.Type]*typeInfo
+
+// getTypeInfo returns the typeInfo for typ, which must be a struct type.
+// Fields are listed in declaration order, which is what lets
+// structToPlistValue produce deterministic dictionary output. Unexported
+// fields and fields tagged `plist:"-"` are omitted; a `plist:"name"` tag
+// overrides the field name and `plist:"name,omitempty"` marks the field
+// to be skipped when empty.
+func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, &UnknownTypeError{Type: typ}
+	}
+
+	if cached, ok := typeInfoCache.Load(typ); ok {
+		return cached.(*typeInfo), nil
+	}
+
+	tinfo := &typeInfo{}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue // unexported field
+		}
+
+		name := sf.Name
+		omitEmpty := false
+		if tag, ok := sf.Tag.Lookup("plist"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		tinfo.fields = append(tinfo.fields, fieldInfo{
+			name:      name,
+			index:     sf.Index,
+			omitEmpty: omitEmpty,
+		})
+	}
+
+	actual, _ := typeInfoCache.LoadOrStore(typ, tinfo)
+	return actual.(*typeInfo), nil
+}
@@ -0,0 +1,131 @@
+package plist
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+`
+
+// xmlGenerator renders a plistValue tree as an Apple XML property list.
+type xmlGenerator struct {
+	w io.Writer
+}
+
+func newXMLGenerator(w io.Writer) *xmlGenerator {
+	return &xmlGenerator{w: w}
+}
+
+func (g *xmlGenerator) generateDocument(root *plistValue) error {
+	if _, err := io.WriteString(g.w, xmlHeader); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(g.w, `<plist version="1.0">`+"\n"); err != nil {
+		return err
+	}
+	if err := g.writeValue(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(g.w, "\n</plist>\n")
+	return err
+}
+
+func (g *xmlGenerator) writeValue(pval *plistValue) error {
+	if pval == nil {
+		return nil
+	}
+
+	switch pval.kind {
+	case Dictionary:
+		keys, values := dictionaryEntries(pval)
+		if _, err := io.WriteString(g.w, "<dict>"); err != nil {
+			return err
+		}
+		for i, key := range keys {
+			if _, err := io.WriteString(g.w, "<key>"+xmlEscape(key)+"</key>"); err != nil {
+				return err
+			}
+			if err := g.writeValue(values[i]); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(g.w, "</dict>")
+		return err
+	case Array:
+		subvalues := pval.value.([]*plistValue)
+		if _, err := io.WriteString(g.w, "<array>"); err != nil {
+			return err
+		}
+		for _, sub := range subvalues {
+			if err := g.writeValue(sub); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(g.w, "</array>")
+		return err
+	case String:
+		_, err := io.WriteString(g.w, "<string>"+xmlEscape(pval.value.(string))+"</string>")
+		return err
+	case Integer:
+		_, err := io.WriteString(g.w, "<integer>"+formatPlistInteger(pval.value)+"</integer>")
+		return err
+	case Real:
+		_, err := io.WriteString(g.w, "<real>"+formatPlistReal(pval.value.(float64))+"</real>")
+		return err
+	case Boolean:
+		tag := "false"
+		if pval.value.(bool) {
+			tag = "true"
+		}
+		_, err := io.WriteString(g.w, "<"+tag+"/>")
+		return err
+	case Data:
+		_, err := io.WriteString(g.w, "<data>"+base64.StdEncoding.EncodeToString(pval.value.([]byte))+"</data>")
+		return err
+	case Date:
+		_, err := io.WriteString(g.w, "<date>"+pval.value.(time.Time).UTC().Format("2006-01-02T15:04:05Z")+"</date>")
+		return err
+	case UIDKind:
+		_, err := io.WriteString(g.w, "<dict><key>CF$UID</key><integer>"+formatPlistInteger(uint64(pval.value.(UID)))+"</integer></dict>")
+		return err
+	default:
+		return unknownKindError(pval)
+	}
+}
+
+func xmlEscape(s string) string {
+	var buf []byte
+	w := &xmlEscapeWriter{buf: buf}
+	xml.EscapeText(w, []byte(s))
+	return string(w.buf)
+}
+
+type xmlEscapeWriter struct {
+	buf []byte
+}
+
+func (w *xmlEscapeWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// formatPlistInteger renders the int64/uint64 payload of an Integer
+// plistValue in decimal, as Apple's plutil does.
+func formatPlistInteger(v interface{}) string {
+	switch n := v.(type) {
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case uint64:
+		return strconv.FormatUint(n, 10)
+	}
+	return "0"
+}
+
+func formatPlistReal(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,195 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// xmlParser builds a plistValue tree from an Apple XML property list
+// document.
+type xmlParser struct {
+	decoder *xml.Decoder
+}
+
+func newXMLParser(data []byte) *xmlParser {
+	return &xmlParser{decoder: xml.NewDecoder(bytes.NewReader(data))}
+}
+
+func (p *xmlParser) parseDocument() (*plistValue, error) {
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name.Local == "plist" {
+				return p.parseChild()
+			}
+		}
+	}
+}
+
+// parseChild reads the next value element (dict/array/string/integer/
+// real/true/false/data/date) and returns its plistValue. It skips whitespace
+// CharData between elements.
+func (p *xmlParser) parseChild() (*plistValue, error) {
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return p.parseElement(t)
+		case xml.EndElement:
+			return nil, nil
+		}
+	}
+}
+
+func (p *xmlParser) parseElement(start xml.StartElement) (*plistValue, error) {
+	switch start.Name.Local {
+	case "dict":
+		return p.parseDict()
+	case "array":
+		return p.parseArray()
+	case "string":
+		s, err := p.textUntilEnd(start.Name)
+		return &plistValue{String, s}, err
+	case "integer":
+		s, err := p.textUntilEnd(start.Name)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &plistValue{Integer, n}, nil
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		return &plistValue{Integer, n}, err
+	case "real":
+		s, err := p.textUntilEnd(start.Name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return &plistValue{Real, f}, err
+	case "true":
+		if err := p.decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return &plistValue{Boolean, true}, nil
+	case "false":
+		if err := p.decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return &plistValue{Boolean, false}, nil
+	case "data":
+		s, err := p.textUntilEnd(start.Name)
+		if err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(removeWhitespace(s))
+		return &plistValue{Data, b}, err
+	case "date":
+		s, err := p.textUntilEnd(start.Name)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("2006-01-02T15:04:05Z", s)
+		return &plistValue{Date, t}, err
+	default:
+		return nil, fmt.Errorf("plist: unknown XML element <%s>", start.Name.Local)
+	}
+}
+
+func (p *xmlParser) parseDict() (*plistValue, error) {
+	pd := &plistDict{}
+	var key string
+	haveKey := false
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "key" {
+				key, err = p.textUntilEnd(t.Name)
+				if err != nil {
+					return nil, err
+				}
+				haveKey = true
+				continue
+			}
+			v, err := p.parseElement(t)
+			if err != nil {
+				return nil, err
+			}
+			if haveKey {
+				pd.keys = append(pd.keys, key)
+				pd.values = append(pd.values, v)
+				haveKey = false
+			}
+		case xml.EndElement:
+			if len(pd.keys) == 1 && pd.keys[0] == "CF$UID" && pd.values[0].kind == Integer {
+				return &plistValue{UIDKind, UID(toUint64(pd.values[0].value))}, nil
+			}
+			return &plistValue{Dictionary, pd}, nil
+		}
+	}
+}
+
+func (p *xmlParser) parseArray() (*plistValue, error) {
+	var subvalues []*plistValue
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			v, err := p.parseElement(t)
+			if err != nil {
+				return nil, err
+			}
+			subvalues = append(subvalues, v)
+		case xml.EndElement:
+			return &plistValue{Array, subvalues}, nil
+		}
+	}
+}
+
+// textUntilEnd accumulates CharData until the matching end element for name
+// and returns it.
+func (p *xmlParser) textUntilEnd(name xml.Name) (string, error) {
+	var buf bytes.Buffer
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			buf.Write(t)
+		case xml.EndElement:
+			if t.Name == name {
+				return buf.String(), nil
+			}
+		}
+	}
+}
+
+func removeWhitespace(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}